@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"log"
 	"net/http"
 	"os"
@@ -18,10 +20,17 @@ func main() {
 	cfg, err := config.Load("config/app.yaml")
 	if err != nil {
 		log.Printf("Failed to load config: %v, using defaults", err)
+		secret, genErr := randomSecret()
+		if genErr != nil {
+			log.Fatalf("Failed to generate a fallback secret: %v", genErr)
+		}
+		log.Printf("No \"secret\" configured; generated a random one for this process only. " +
+			"Sessions' CSRF tokens will stop validating on restart — set \"secret\" in config/app.yaml for production.")
 		cfg = &config.Config{
 			Port:     "8080",
 			DBDriver: "sqlite3",
 			DBDSN:    "vulnerable.db",
+			Secret:   secret,
 		}
 	}
 
@@ -33,10 +42,10 @@ func main() {
 	defer database.Close()
 
 	// Initialize session store
-	sessionStore := security.NewSessionStore()
+	sessionStore := security.NewSessionStore(database)
 
 	// Setup router
-	r := router.Setup(database, sessionStore)
+	r := router.Setup(database, sessionStore, cfg)
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -49,3 +58,11 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+func randomSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
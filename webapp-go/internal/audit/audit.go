@@ -0,0 +1,38 @@
+// Package audit emits structured JSON security-audit events (login
+// attempts, admin actions, file access, rate-limit denials) via log/slog,
+// tagged with the caller's remote IP and the request's trace ID.
+package audit
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"webapp-go/internal/middleware/requestid"
+)
+
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// Event logs a single audit event for r. userID is 0 when the caller isn't
+// authenticated. extra is appended as additional slog key/value pairs.
+func Event(r *http.Request, event string, status int, userID int, extra ...any) {
+	args := []any{
+		"event", event,
+		"remote_ip", RemoteIP(r),
+		"route", r.URL.Path,
+		"status", status,
+		"user_id", userID,
+		"trace_id", requestid.FromContext(r.Context()),
+	}
+	args = append(args, extra...)
+	logger.Info(event, args...)
+}
+
+// RemoteIP returns r's caller address with any port stripped.
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,433 @@
+// Package oidc implements an OAuth2/OIDC authorization-code login flow with
+// PKCE on top of golang.org/x/oauth2, so operators can authenticate users
+// against an external identity provider instead of (or alongside) local
+// passwords.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"webapp-go/internal/config"
+	"webapp-go/internal/db"
+	"webapp-go/internal/models"
+	"webapp-go/internal/security"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	flowCookieName = "oidc_flow"
+	flowTTL        = 5 * time.Minute
+)
+
+// pendingFlow tracks the PKCE verifier and state for a login that has been
+// started but not yet completed.
+type pendingFlow struct {
+	state    string
+	verifier string
+	expires  time.Time
+}
+
+// Provider drives the login/callback handlers for a single configured OIDC
+// identity provider.
+type Provider struct {
+	oauth2  *oauth2.Config
+	db      *db.DB
+	sec     *security.SessionStore
+	jwksURL string
+	issuer  string
+
+	mu      sync.Mutex
+	pending map[string]pendingFlow
+}
+
+// NewProvider builds a Provider from the oauth_* keys in cfg. It returns nil
+// if OAuthClientID is unset, meaning OIDC login is disabled. It also returns
+// nil (refusing to start) if OAuthJWKSURL is unset, since without it the ID
+// token's signature can never be verified.
+func NewProvider(cfg *config.Config, database *db.DB, sec *security.SessionStore) *Provider {
+	if cfg.OAuthClientID == "" {
+		return nil
+	}
+	if cfg.OAuthJWKSURL == "" {
+		log.Printf("oidc: oauth_jwks_url is required to verify ID tokens; OIDC login disabled")
+		return nil
+	}
+
+	return &Provider{
+		oauth2: &oauth2.Config{
+			ClientID:     cfg.OAuthClientID,
+			ClientSecret: cfg.OAuthClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  cfg.OAuthAuthURL,
+				TokenURL: cfg.OAuthTokenURL,
+			},
+			RedirectURL: cfg.OAuthRedirectURL,
+			Scopes:      cfg.OAuthScopes,
+		},
+		db:      database,
+		sec:     sec,
+		jwksURL: cfg.OAuthJWKSURL,
+		issuer:  cfg.OAuthIssuer,
+		pending: make(map[string]pendingFlow),
+	}
+}
+
+// StartLogin begins the authorization-code-with-PKCE flow: it generates a
+// verifier/state pair, stashes them server-side keyed by a random flow ID
+// stored in an HttpOnly cookie, and redirects the browser to the provider's
+// authorization endpoint.
+func (p *Provider) StartLogin(w http.ResponseWriter, r *http.Request) {
+	verifier, err := randomURLSafeString(32)
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	state, err := randomURLSafeString(16)
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	flowID, err := randomURLSafeString(16)
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	challenge := codeChallengeS256(verifier)
+
+	p.storeFlow(flowID, pendingFlow{
+		state:    state,
+		verifier: verifier,
+		expires:  time.Now().Add(flowTTL),
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     flowCookieName,
+		Value:    flowID,
+		Path:     "/api/auth/oidc",
+		MaxAge:   int(flowTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	authURL := p.oauth2.AuthCodeURL(state,
+		oauth2.SetAuthURLParam("code_challenge", challenge),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback validates the state, exchanges the authorization code (presenting
+// the stashed PKCE verifier), verifies and parses the ID token claims, then
+// upserts a local user keyed by the "sub" claim and issues a local session.
+func (p *Provider) Callback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(flowCookieName)
+	if err != nil {
+		http.Error(w, "Login flow expired or missing", http.StatusBadRequest)
+		return
+	}
+
+	flow, ok := p.takeFlow(cookie.Value)
+	clearFlowCookie(w)
+	if !ok || time.Now().After(flow.expires) {
+		http.Error(w, "Login flow expired or missing", http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Query().Get("state") != flow.state {
+		http.Error(w, "Invalid OAuth state", http.StatusBadRequest)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Error(w, "Missing authorization code", http.StatusBadRequest)
+		return
+	}
+
+	token, err := p.oauth2.Exchange(r.Context(), code,
+		oauth2.SetAuthURLParam("code_verifier", flow.verifier))
+	if err != nil {
+		http.Error(w, "Failed to exchange authorization code", http.StatusBadGateway)
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		http.Error(w, "No id_token in token response", http.StatusBadGateway)
+		return
+	}
+
+	claims, err := p.verifyIDToken(r.Context(), rawIDToken)
+	if err != nil {
+		http.Error(w, "Invalid ID token: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	user, err := p.upsertUser(claims)
+	if err != nil {
+		http.Error(w, "Failed to provision user", http.StatusInternalServerError)
+		return
+	}
+
+	sessionID, err := p.sec.CreateSession(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_id",
+		Value:    sessionID,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Login successful"})
+}
+
+func (p *Provider) upsertUser(claims *idClaims) (*models.User, error) {
+	user, err := p.db.Users.GetBySub(claims.Sub)
+	if err == nil {
+		return user, nil
+	}
+
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Email
+	}
+	if username == "" {
+		username = "oidc_" + claims.Sub
+	}
+
+	return p.db.Users.CreateOIDC(claims.Sub, username, claims.Email)
+}
+
+func (p *Provider) storeFlow(id string, flow pendingFlow) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k, v := range p.pending {
+		if time.Now().After(v.expires) {
+			delete(p.pending, k)
+		}
+	}
+	p.pending[id] = flow
+}
+
+func (p *Provider) takeFlow(id string) (pendingFlow, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	flow, ok := p.pending[id]
+	delete(p.pending, id)
+	return flow, ok
+}
+
+func clearFlowCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     flowCookieName,
+		Value:    "",
+		Path:     "/api/auth/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+// idClaims is the subset of standard OIDC claims this package cares about.
+type idClaims struct {
+	Sub               string   `json:"sub"`
+	Email             string   `json:"email"`
+	PreferredUsername string   `json:"preferred_username"`
+	Exp               int64    `json:"exp"`
+	Iss               string   `json:"iss"`
+	Aud               audience `json:"aud"`
+}
+
+// audience accepts the "aud" claim in either of its legal JSON shapes: a
+// single string, or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = audience(list)
+	return nil
+}
+
+func (a audience) contains(v string) bool {
+	for _, candidate := range a {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
+
+// jwk is a single entry from a JWKS document, restricted to the RSA fields
+// this package understands (RS256 is the only algorithm supported).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwksDocument struct {
+	Keys []jwk `json:"keys"`
+}
+
+// verifyIDToken checks the ID token's RS256 signature against the
+// provider's JWKS, then validates exp, aud (must include our client ID),
+// and iss (must match the configured issuer, when one is configured) before
+// returning the claims as trustworthy.
+func (p *Provider) verifyIDToken(ctx context.Context, raw string) (*idClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed id_token")
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("decode id_token header: %w", err)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, fmt.Errorf("unmarshal id_token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported id_token signing algorithm %q", header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decode id_token signature: %w", err)
+	}
+
+	pubKey, err := p.fetchSigningKey(ctx, header.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("fetch signing key: %w", err)
+	}
+
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(pubKey, crypto.SHA256, hashed[:], sig); err != nil {
+		return nil, fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("decode id_token payload: %w", err)
+	}
+	var claims idClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("unmarshal id_token claims: %w", err)
+	}
+
+	if claims.Sub == "" {
+		return nil, fmt.Errorf("id_token missing sub claim")
+	}
+	if claims.Exp == 0 || time.Now().Unix() >= claims.Exp {
+		return nil, fmt.Errorf("id_token expired")
+	}
+	if !claims.Aud.contains(p.oauth2.ClientID) {
+		return nil, fmt.Errorf("id_token aud does not include our client id")
+	}
+	if p.issuer != "" && claims.Iss != p.issuer {
+		return nil, fmt.Errorf("id_token iss %q does not match configured issuer", claims.Iss)
+	}
+
+	return &claims, nil
+}
+
+// fetchSigningKey retrieves the provider's JWKS document and returns the RSA
+// public key matching kid. It's fetched fresh on every call rather than
+// cached, since ID token verification only happens once per login.
+func (p *Provider) fetchSigningKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks endpoint returned status %d", resp.StatusCode)
+	}
+
+	var doc jwksDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+
+	for _, key := range doc.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+		if key.Kid != "" && kid != "" && key.Kid != kid {
+			continue
+		}
+		return rsaPublicKeyFromJWK(key)
+	}
+
+	return nil, fmt.Errorf("no matching RSA key for kid %q", kid)
+}
+
+func rsaPublicKeyFromJWK(key jwk) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		return nil, fmt.Errorf("decode modulus: %w", err)
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil {
+		return nil, fmt.Errorf("decode exponent: %w", err)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: int(new(big.Int).SetBytes(eBytes).Int64()),
+	}, nil
+}
+
+func randomURLSafeString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func codeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
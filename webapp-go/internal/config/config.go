@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 
 	"gopkg.in/yaml.v2"
@@ -11,6 +12,39 @@ type Config struct {
 	DBDriver string `yaml:"db_driver"`
 	DBDSN    string `yaml:"db_dsn"`
 	Secret   string `yaml:"secret"`
+
+	// OAuth2/OIDC configuration. OAuthClientID is left empty to disable the
+	// /api/auth/oidc/* routes entirely. OAuthJWKSURL and OAuthIssuer are
+	// required to verify the ID token's signature and claims; the provider
+	// is disabled (as if OAuthClientID were unset) if OAuthJWKSURL is
+	// missing, rather than accepting unverified tokens.
+	OAuthClientID     string   `yaml:"oauth_client_id"`
+	OAuthClientSecret string   `yaml:"oauth_client_secret"`
+	OAuthAuthURL      string   `yaml:"oauth_auth_url"`
+	OAuthTokenURL     string   `yaml:"oauth_token_url"`
+	OAuthScopes       []string `yaml:"oauth_scopes"`
+	OAuthRedirectURL  string   `yaml:"oauth_redirect_url"`
+	OAuthJWKSURL      string   `yaml:"oauth_jwks_url"`
+	OAuthIssuer       string   `yaml:"oauth_issuer"`
+
+	// Argon2id cost parameters for password hashing. Zero values fall back
+	// to security.DefaultArgon2Params.
+	Argon2Time    uint32 `yaml:"argon2_time"`
+	Argon2Memory  uint32 `yaml:"argon2_memory"`
+	Argon2Threads uint8  `yaml:"argon2_threads"`
+	Argon2KeyLen  uint32 `yaml:"argon2_key_len"`
+	Argon2SaltLen uint32 `yaml:"argon2_salt_len"`
+
+	// File upload limits. Zero values fall back to
+	// handlers.DefaultFileUploadLimits.
+	MaxFileSize         int64    `yaml:"max_file_size"`
+	MaxUserStorageQuota int64    `yaml:"max_user_storage_quota"`
+	AllowedContentTypes []string `yaml:"allowed_content_types"`
+
+	// RateLimitStore selects the ratelimit.Store backend. Only "memory" (the
+	// default) is implemented today; it's a config knob so a shared backend
+	// like Redis can be added later without changing call sites.
+	RateLimitStore string `yaml:"rate_limit_store"`
 }
 
 func Load(filename string) (*Config, error) {
@@ -24,5 +58,12 @@ func Load(filename string) (*Config, error) {
 		return nil, err
 	}
 
+	// Secret backs the CSRF token HMAC (security.NewCSRFProtector); an empty
+	// value would mean every CSRF token is HMAC(sessionID, ""), so refuse to
+	// start rather than silently running with a degraded defense.
+	if config.Secret == "" {
+		return nil, fmt.Errorf("config: %q is required", "secret")
+	}
+
 	return &config, nil
 }
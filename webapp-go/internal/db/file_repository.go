@@ -0,0 +1,121 @@
+package db
+
+import (
+	"database/sql"
+	"webapp-go/internal/models"
+)
+
+// FileRepository holds statements for the files table, prepared once at
+// Init time and reused for the lifetime of the process.
+type FileRepository struct {
+	save          *sql.Stmt
+	getByUser     *sql.Stmt
+	getByID       *sql.Stmt
+	sumSizeByUser *sql.Stmt
+	countBySHA256 *sql.Stmt
+	delete        *sql.Stmt
+}
+
+func newFileRepository(conn *sql.DB) (*FileRepository, error) {
+	repo := &FileRepository{}
+
+	var err error
+	if repo.save, err = conn.Prepare(
+		"INSERT INTO files (filename, filepath, user_id, sha256, size) VALUES (?, ?, ?, ?, ?)"); err != nil {
+		return nil, err
+	}
+	if repo.getByUser, err = conn.Prepare(
+		"SELECT id, filename, filepath, user_id, sha256, size, uploaded_at FROM files WHERE user_id = ?"); err != nil {
+		return nil, err
+	}
+	if repo.getByID, err = conn.Prepare(
+		"SELECT id, filename, filepath, user_id, sha256, size, uploaded_at FROM files WHERE id = ?"); err != nil {
+		return nil, err
+	}
+	if repo.sumSizeByUser, err = conn.Prepare(
+		"SELECT COALESCE(SUM(size), 0) FROM files WHERE user_id = ?"); err != nil {
+		return nil, err
+	}
+	if repo.countBySHA256, err = conn.Prepare(
+		"SELECT COUNT(*) FROM files WHERE user_id = ? AND sha256 = ?"); err != nil {
+		return nil, err
+	}
+	if repo.delete, err = conn.Prepare("DELETE FROM files WHERE id = ?"); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (r *FileRepository) Save(filename, filepath string, userID int, sha256 string, size int64) (*models.File, error) {
+	res, err := r.save.Exec(filename, filepath, userID, sha256, size)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.File{
+		ID:       int(id),
+		Filename: filename,
+		Filepath: filepath,
+		UserID:   userID,
+		SHA256:   sha256,
+		Size:     size,
+	}, nil
+}
+
+func (r *FileRepository) GetByUser(userID int) ([]models.File, error) {
+	rows, err := r.getByUser.Query(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []models.File
+	for rows.Next() {
+		var file models.File
+		if err := rows.Scan(&file.ID, &file.Filename, &file.Filepath, &file.UserID, &file.SHA256, &file.Size, &file.UploadedAt); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// GetByID looks up a single file record, used to enforce ownership before a
+// download or delete.
+func (r *FileRepository) GetByID(id int) (*models.File, error) {
+	file := &models.File{}
+	err := r.getByID.QueryRow(id).Scan(&file.ID, &file.Filename, &file.Filepath, &file.UserID, &file.SHA256, &file.Size, &file.UploadedAt)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// SumSizeByUser returns the total bytes a user currently has stored, used to
+// enforce a per-user quota on upload.
+func (r *FileRepository) SumSizeByUser(userID int) (int64, error) {
+	var total int64
+	err := r.sumSizeByUser.QueryRow(userID).Scan(&total)
+	return total, err
+}
+
+// CountByUserAndSHA256 reports how many of a user's file rows reference a
+// given content hash. UploadFile content-addresses the on-disk path by hash
+// alone, so identical-content uploads for the same user share one blob;
+// callers must check this is down to zero before unlinking it on delete.
+func (r *FileRepository) CountByUserAndSHA256(userID int, sha256 string) (int, error) {
+	var count int
+	err := r.countBySHA256.QueryRow(userID, sha256).Scan(&count)
+	return count, err
+}
+
+func (r *FileRepository) Delete(id int) error {
+	_, err := r.delete.Exec(id)
+	return err
+}
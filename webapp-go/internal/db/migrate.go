@@ -0,0 +1,58 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"sort"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// Migrate applies any .sql files under migrations/ that haven't been run
+// against db yet, in filename order, recording each as it succeeds in
+// schema_migrations. Migrations are numbered (0001_init.sql, 0002_...)
+// rather than baked into a single createTables call, so schema changes can
+// ship incrementally alongside the code that needs them.
+func Migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version TEXT PRIMARY KEY,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+
+	entries, err := migrationFS.ReadDir("migrations")
+	if err != nil {
+		return fmt.Errorf("read migrations: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		version := entry.Name()
+
+		var applied int
+		row := db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = ?", version)
+		if err := row.Scan(&applied); err != nil {
+			return fmt.Errorf("check migration %s: %w", version, err)
+		}
+		if applied > 0 {
+			continue
+		}
+
+		contents, err := migrationFS.ReadFile("migrations/" + version)
+		if err != nil {
+			return fmt.Errorf("read migration %s: %w", version, err)
+		}
+
+		if _, err := db.Exec(string(contents)); err != nil {
+			return fmt.Errorf("apply migration %s: %w", version, err)
+		}
+		if _, err := db.Exec("INSERT INTO schema_migrations (version) VALUES (?)", version); err != nil {
+			return fmt.Errorf("record migration %s: %w", version, err)
+		}
+	}
+
+	return nil
+}
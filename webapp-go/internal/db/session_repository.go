@@ -0,0 +1,54 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+	"webapp-go/internal/models"
+)
+
+// SessionRepository holds statements for the sessions table, prepared once
+// at Init time and reused for the lifetime of the process.
+type SessionRepository struct {
+	create *sql.Stmt
+	get    *sql.Stmt
+	delete *sql.Stmt
+}
+
+func newSessionRepository(conn *sql.DB) (*SessionRepository, error) {
+	repo := &SessionRepository{}
+
+	var err error
+	if repo.create, err = conn.Prepare(
+		"INSERT INTO sessions (id, user_id, expires_at) VALUES (?, ?, ?)"); err != nil {
+		return nil, err
+	}
+	if repo.get, err = conn.Prepare(
+		"SELECT id, user_id, expires_at FROM sessions WHERE id = ?"); err != nil {
+		return nil, err
+	}
+	if repo.delete, err = conn.Prepare(
+		"DELETE FROM sessions WHERE id = ?"); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (r *SessionRepository) Create(sessionID string, userID int, expiresAt time.Time) error {
+	_, err := r.create.Exec(sessionID, userID, expiresAt)
+	return err
+}
+
+func (r *SessionRepository) Get(sessionID string) (*models.Session, error) {
+	session := &models.Session{}
+	err := r.get.QueryRow(sessionID).Scan(&session.ID, &session.UserID, &session.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (r *SessionRepository) Delete(sessionID string) error {
+	_, err := r.delete.Exec(sessionID)
+	return err
+}
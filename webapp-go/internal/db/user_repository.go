@@ -0,0 +1,175 @@
+package db
+
+import (
+	"database/sql"
+	"webapp-go/internal/models"
+)
+
+// UserRepository holds statements for the users table, prepared once at
+// Init time and reused for the lifetime of the process.
+type UserRepository struct {
+	getByUsername      *sql.Stmt
+	getByID            *sql.Stmt
+	getBySub           *sql.Stmt
+	create             *sql.Stmt
+	createOIDC         *sql.Stmt
+	search             *sql.Stmt
+	getAll             *sql.Stmt
+	updatePasswordHash *sql.Stmt
+	delete             *sql.Stmt
+}
+
+func newUserRepository(conn *sql.DB) (*UserRepository, error) {
+	repo := &UserRepository{}
+
+	var err error
+	if repo.getByUsername, err = conn.Prepare(
+		"SELECT id, username, password_hash, email, is_admin FROM users WHERE username = ?"); err != nil {
+		return nil, err
+	}
+	if repo.getByID, err = conn.Prepare(
+		"SELECT id, username, password_hash, email, is_admin FROM users WHERE id = ?"); err != nil {
+		return nil, err
+	}
+	if repo.getBySub, err = conn.Prepare(
+		"SELECT id, username, password_hash, email, is_admin, oidc_sub FROM users WHERE oidc_sub = ?"); err != nil {
+		return nil, err
+	}
+	if repo.create, err = conn.Prepare(
+		"INSERT INTO users (username, password_hash, email) VALUES (?, ?, ?)"); err != nil {
+		return nil, err
+	}
+	if repo.createOIDC, err = conn.Prepare(
+		"INSERT INTO users (username, password_hash, email, oidc_sub) VALUES (?, '', ?, ?)"); err != nil {
+		return nil, err
+	}
+	if repo.search, err = conn.Prepare(
+		"SELECT id, username, email, is_admin FROM users WHERE username LIKE '%' || ? || '%' OR email LIKE '%' || ? || '%'"); err != nil {
+		return nil, err
+	}
+	if repo.getAll, err = conn.Prepare(
+		"SELECT id, username, email, is_admin FROM users"); err != nil {
+		return nil, err
+	}
+	if repo.updatePasswordHash, err = conn.Prepare(
+		"UPDATE users SET password_hash = ? WHERE id = ?"); err != nil {
+		return nil, err
+	}
+	if repo.delete, err = conn.Prepare("DELETE FROM users WHERE id = ?"); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
+	user := &models.User{}
+	err := r.getByUsername.QueryRow(username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Email, &user.IsAdmin)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *UserRepository) GetByID(id int) (*models.User, error) {
+	user := &models.User{}
+	err := r.getByID.QueryRow(id).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Email, &user.IsAdmin)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetBySub looks up a user previously provisioned via an OIDC login by the
+// provider's "sub" claim.
+func (r *UserRepository) GetBySub(sub string) (*models.User, error) {
+	user := &models.User{}
+	err := r.getBySub.QueryRow(sub).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Email, &user.IsAdmin, &user.OIDCSubject)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *UserRepository) Create(username, passwordHash, email string) error {
+	_, err := r.create.Exec(username, passwordHash, email)
+	return err
+}
+
+// CreateOIDC provisions a local user row for a subject seen for the first
+// time via an OIDC login. There is no local password, so password_hash is
+// left empty; such users can only authenticate via the OIDC flow.
+func (r *UserRepository) CreateOIDC(sub, username, email string) (*models.User, error) {
+	res, err := r.createOIDC.Exec(username, email, sub)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.User{ID: int(id), Username: username, Email: email, OIDCSubject: sub}, nil
+}
+
+// UpdatePasswordHash rewrites a user's stored hash, e.g. after a
+// transparent rehash to updated Argon2id parameters.
+func (r *UserRepository) UpdatePasswordHash(userID int, passwordHash string) error {
+	_, err := r.updatePasswordHash.Exec(passwordHash, userID)
+	return err
+}
+
+// Delete removes a user row, returning sql.ErrNoRows if no user had that ID.
+// It does not cascade to sessions or files; callers that need that should
+// clean those up first.
+func (r *UserRepository) Delete(userID int) error {
+	res, err := r.delete.Exec(userID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *UserRepository) Search(term string) ([]models.User, error) {
+	rows, err := r.search.Query(term, term)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.IsAdmin); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (r *UserRepository) GetAll() ([]models.User, error) {
+	rows, err := r.getAll.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.IsAdmin); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
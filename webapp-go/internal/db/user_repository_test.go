@@ -0,0 +1,49 @@
+package db
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// TestUserRepository_GetByUsername_ParameterizesInput proves that a
+// malicious username is bound as a query parameter rather than concatenated
+// into the SQL text, so it can never change the shape of the executed
+// statement (e.g. a classic "'; DROP TABLE users;--" injection payload).
+func TestUserRepository_GetByUsername_ParameterizesInput(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer conn.Close()
+
+	maliciousUsername := "'; DROP TABLE users;--"
+
+	// newUserRepository prepares a statement per method; match any of them
+	// generically and only assert precisely on the one GetByUsername uses.
+	mock.MatchExpectationsInOrder(false)
+	for i := 0; i < 9; i++ {
+		mock.ExpectPrepare(".*")
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT id, username, password_hash, email, is_admin FROM users WHERE username = ?")).
+		WithArgs(maliciousUsername).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "email", "is_admin"}))
+
+	repo, err := newUserRepository(conn)
+	if err != nil {
+		t.Fatalf("failed to prepare repository: %v", err)
+	}
+
+	// No rows match, so this should surface sql.ErrNoRows rather than ever
+	// executing a second statement (which is what a real DROP TABLE
+	// injection would require).
+	if _, err := repo.GetByUsername(maliciousUsername); err == nil {
+		t.Fatalf("expected no-rows error for unmatched username")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"webapp-go/internal/audit"
 	"webapp-go/internal/db"
+	"webapp-go/internal/security"
 )
 
 type AdminHandler struct {
@@ -14,15 +18,13 @@ func NewAdminHandler(db *db.DB) *AdminHandler {
 	return &AdminHandler{db: db}
 }
 
-// Vulnerability: No authentication check - anyone can access
 func (h *AdminHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Vulnerability: No admin role verification
-	users, err := h.db.GetAllUsers()
+	users, err := h.db.Users.GetAll()
 	if err != nil {
 		http.Error(w, "Failed to get users", http.StatusInternalServerError)
 		return
@@ -32,7 +34,6 @@ func (h *AdminHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(users)
 }
 
-// Vulnerability: No authentication check - anyone can search users
 func (h *AdminHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -46,8 +47,7 @@ func (h *AdminHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Vulnerability: SQL injection possible in SearchUsers
-	users, err := h.db.SearchUsers(searchTerm)
+	users, err := h.db.Users.Search(searchTerm)
 	if err != nil {
 		http.Error(w, "Failed to search users", http.StatusInternalServerError)
 		return
@@ -57,22 +57,36 @@ func (h *AdminHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(users)
 }
 
-// Vulnerability: No authentication check - anyone can delete users
 func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Vulnerability: No input validation
-	userID := r.URL.Query().Get("id")
-	if userID == "" {
+	userIDParam := r.URL.Query().Get("id")
+	if userIDParam == "" {
 		http.Error(w, "User ID required", http.StatusBadRequest)
 		return
 	}
+	userID, err := strconv.Atoi(userIDParam)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	actor, _ := security.UserFromContext(r.Context())
+
+	if err := h.db.Users.Delete(userID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+
+	audit.Event(r, "admin.delete_user", http.StatusOK, actor.ID, "target_user_id", userID)
 
-	// Vulnerability: No admin role verification
-	// Vulnerability: SQL injection possible (though not implemented in this example)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "User deleted successfully"})
 }
@@ -1,139 +1,264 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"webapp-go/internal/audit"
+	"webapp-go/internal/config"
 	"webapp-go/internal/db"
+	"webapp-go/internal/models"
+	"webapp-go/internal/security"
 )
 
+// DefaultFileUploadLimits are used when config leaves the upload-related
+// keys unset.
+var DefaultFileUploadLimits = struct {
+	MaxFileSize         int64
+	MaxUserStorageQuota int64
+	AllowedContentTypes []string
+}{
+	MaxFileSize:         10 * 1024 * 1024,  // 10MB
+	MaxUserStorageQuota: 100 * 1024 * 1024, // 100MB
+	AllowedContentTypes: []string{
+		"image/jpeg",
+		"image/png",
+		"image/gif",
+		"application/pdf",
+		"text/plain; charset=utf-8",
+	},
+}
+
 type FileHandler struct {
-	db          *db.DB
-	uploadDir   string
-	maxFileSize int64
+	db                  *db.DB
+	uploadDir           string
+	maxFileSize         int64
+	maxUserStorageQuota int64
+	allowedContentTypes map[string]bool
+}
+
+func NewFileHandler(database *db.DB) *FileHandler {
+	return newFileHandler(database, DefaultFileUploadLimits.MaxFileSize, DefaultFileUploadLimits.MaxUserStorageQuota, DefaultFileUploadLimits.AllowedContentTypes)
 }
 
-func NewFileHandler(db *db.DB) *FileHandler {
+// NewFileHandlerFromConfig builds a FileHandler from the upload-related
+// config keys, falling back to DefaultFileUploadLimits for any left unset.
+func NewFileHandlerFromConfig(database *db.DB, cfg *config.Config) *FileHandler {
+	maxFileSize := cfg.MaxFileSize
+	if maxFileSize == 0 {
+		maxFileSize = DefaultFileUploadLimits.MaxFileSize
+	}
+	maxQuota := cfg.MaxUserStorageQuota
+	if maxQuota == 0 {
+		maxQuota = DefaultFileUploadLimits.MaxUserStorageQuota
+	}
+	allowedContentTypes := cfg.AllowedContentTypes
+	if len(allowedContentTypes) == 0 {
+		allowedContentTypes = DefaultFileUploadLimits.AllowedContentTypes
+	}
+
+	return newFileHandler(database, maxFileSize, maxQuota, allowedContentTypes)
+}
+
+func newFileHandler(database *db.DB, maxFileSize, maxUserStorageQuota int64, allowedContentTypes []string) *FileHandler {
+	allowed := make(map[string]bool, len(allowedContentTypes))
+	for _, ct := range allowedContentTypes {
+		allowed[ct] = true
+	}
+
 	return &FileHandler{
-		db:          db,
-		uploadDir:   "uploads/",
-		maxFileSize: 10 * 1024 * 1024, // 10MB
+		db:                  database,
+		uploadDir:           "uploads/",
+		maxFileSize:         maxFileSize,
+		maxUserStorageQuota: maxUserStorageQuota,
+		allowedContentTypes: allowed,
 	}
 }
 
-// Vulnerability: Path traversal, no file type validation, no size limits enforced
+// UploadFile streams the upload into a temp file while hashing it, then
+// moves it to a content-addressed path (uploads/<user_id>/<sha256>) so two
+// uploads with identical bytes share storage and the on-disk path never
+// depends on attacker-controlled input.
 func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Vulnerability: No authentication check
-	// Vulnerability: No file size limit enforcement
+	user, _ := security.UserFromContext(r.Context())
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxFileSize)
+
 	file, header, err := r.FormFile("file")
 	if err != nil {
+		if err.Error() == "http: request body too large" {
+			http.Error(w, "File exceeds maximum upload size", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Failed to get file", http.StatusBadRequest)
 		return
 	}
 	defer file.Close()
 
-	// Vulnerability: No file type validation
-	// Vulnerability: Path traversal possible via filename
-	filename := header.Filename
-	if filename == "" {
+	if header.Filename == "" {
 		http.Error(w, "No filename provided", http.StatusBadRequest)
 		return
 	}
 
-	// Vulnerability: No sanitization of filename
-	// Vulnerability: Path traversal possible
-	filepath := filepath.Join(h.uploadDir, filename)
+	used, err := h.db.Files.SumSizeByUser(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to check storage quota", http.StatusInternalServerError)
+		return
+	}
+	if used+header.Size > h.maxUserStorageQuota {
+		http.Error(w, "Storage quota exceeded", http.StatusRequestEntityTooLarge)
+		return
+	}
 
-	// Create upload directory if it doesn't exist
-	if err := os.MkdirAll(h.uploadDir, 0755); err != nil {
+	userDir := filepath.Join(h.uploadDir, strconv.Itoa(user.ID))
+	if err := os.MkdirAll(userDir, 0755); err != nil {
 		http.Error(w, "Failed to create upload directory", http.StatusInternalServerError)
 		return
 	}
 
-	// Create the file
-	dst, err := os.Create(filepath)
+	tmp, err := os.CreateTemp(userDir, ".upload-*")
 	if err != nil {
 		http.Error(w, "Failed to create file", http.StatusInternalServerError)
 		return
 	}
-	defer dst.Close()
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
 
-	// Copy file content
-	if _, err := io.Copy(dst, file); err != nil {
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(hasher, tmp), file)
+	if err != nil {
+		if err.Error() == "http: request body too large" {
+			http.Error(w, "File exceeds maximum upload size", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Failed to save file", http.StatusInternalServerError)
 		return
 	}
 
-	// Vulnerability: No user ID validation - hardcoded to 1
-	userID := 1
-	if err := h.db.SaveFile(filename, filepath, userID); err != nil {
+	sniffBuf := make([]byte, 512)
+	n, _ := tmp.ReadAt(sniffBuf, 0)
+	contentType := http.DetectContentType(sniffBuf[:n])
+	if !h.allowedContentTypes[contentType] {
+		http.Error(w, "File type not allowed", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	destPath := filepath.Join(userDir, sum)
+
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), destPath); err != nil {
+		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		return
+	}
+
+	saved, err := h.db.Files.Save(header.Filename, destPath, user.ID, sum, size)
+	if err != nil {
+		os.Remove(destPath)
 		http.Error(w, "Failed to save file info", http.StatusInternalServerError)
 		return
 	}
 
+	audit.Event(r, "file.upload", http.StatusCreated, user.ID, "file_id", saved.ID, "sha256", sum)
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{
 		"message":  "File uploaded successfully",
-		"filename": filename,
+		"filename": header.Filename,
 	})
 }
 
-// Vulnerability: Path traversal, no access control
+// resolveOwnedFile looks up a file by id and confirms the caller is allowed
+// to act on it (its owner, or an admin), refusing any on-disk path that
+// escapes the upload root.
+func (h *FileHandler) resolveOwnedFile(r *http.Request) (*models.File, int, error) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		return nil, http.StatusBadRequest, errors.New("file id required")
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("invalid file id")
+	}
+
+	file, err := h.db.Files.GetByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, http.StatusNotFound, errors.New("file not found")
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+
+	user, _ := security.UserFromContext(r.Context())
+	if file.UserID != user.ID && !user.IsAdmin {
+		return nil, http.StatusForbidden, errors.New("not your file")
+	}
+
+	root, err := filepath.Abs(h.uploadDir)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	abs, err := filepath.Abs(file.Filepath)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, http.StatusForbidden, errors.New("file path escapes upload root")
+	}
+
+	return file, http.StatusOK, nil
+}
+
 func (h *FileHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Vulnerability: No authentication check
-	// Vulnerability: Path traversal possible via filename parameter
-	filename := r.URL.Query().Get("file")
-	if filename == "" {
-		http.Error(w, "Filename required", http.StatusBadRequest)
+	record, status, err := h.resolveOwnedFile(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
 		return
 	}
 
-	// Vulnerability: No path sanitization
-	// Vulnerability: Path traversal possible
-	filepath := filepath.Join(h.uploadDir, filename)
-
-	// Vulnerability: No access control - can download any file
-	// Vulnerability: Path traversal can access files outside upload directory
-	file, err := os.Open(filepath)
+	f, err := os.Open(record.Filepath)
 	if err != nil {
 		http.Error(w, "File not found", http.StatusNotFound)
 		return
 	}
-	defer file.Close()
+	defer f.Close()
 
-	// Set content type
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	user, _ := security.UserFromContext(r.Context())
+	audit.Event(r, "file.download", http.StatusOK, user.ID, "file_id", record.ID)
 
-	// Copy file to response
-	io.Copy(w, file)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(record.Filename))
+	io.Copy(w, f)
 }
 
-// Vulnerability: No access control - can list any user's files
 func (h *FileHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Vulnerability: No authentication check
-	// Vulnerability: No user ID validation - hardcoded to 1
-	userID := 1
+	user, _ := security.UserFromContext(r.Context())
 
-	files, err := h.db.GetUserFiles(userID)
+	files, err := h.db.Files.GetByUser(user.ID)
 	if err != nil {
 		http.Error(w, "Failed to get files", http.StatusInternalServerError)
 		return
@@ -143,32 +268,38 @@ func (h *FileHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(files)
 }
 
-// Vulnerability: Path traversal, no access control
 func (h *FileHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Vulnerability: No authentication check
-	// Vulnerability: Path traversal possible via filename parameter
-	filename := r.URL.Query().Get("file")
-	if filename == "" {
-		http.Error(w, "Filename required", http.StatusBadRequest)
+	record, status, err := h.resolveOwnedFile(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
 		return
 	}
 
-	// Vulnerability: No path sanitization
-	// Vulnerability: Path traversal possible
-	filepath := filepath.Join(h.uploadDir, filename)
-
-	// Vulnerability: No access control - can delete any file
-	// Vulnerability: Path traversal can delete files outside upload directory
-	if err := os.Remove(filepath); err != nil {
+	if err := h.db.Files.Delete(record.ID); err != nil {
 		http.Error(w, "Failed to delete file", http.StatusInternalServerError)
 		return
 	}
 
+	// UploadFile content-addresses the blob by hash alone, so another row
+	// for this user may still point at the same path; only unlink it once
+	// no row references that hash anymore.
+	remaining, err := h.db.Files.CountByUserAndSHA256(record.UserID, record.SHA256)
+	if err != nil {
+		http.Error(w, "Failed to finalize delete", http.StatusInternalServerError)
+		return
+	}
+	if remaining == 0 {
+		_ = os.Remove(record.Filepath)
+	}
+
+	user, _ := security.UserFromContext(r.Context())
+	audit.Event(r, "file.delete", http.StatusOK, user.ID, "file_id", record.ID)
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "File deleted successfully"})
 }
@@ -1,48 +1,88 @@
 package router
 
 import (
+	"log"
 	"net/http"
+	"time"
+	"webapp-go/internal/audit"
+	"webapp-go/internal/auth/oidc"
+	"webapp-go/internal/config"
 	"webapp-go/internal/db"
 	"webapp-go/internal/http/handlers"
+	"webapp-go/internal/middleware/ratelimit"
+	"webapp-go/internal/middleware/requestid"
 	"webapp-go/internal/security"
 
 	"github.com/gorilla/mux"
 )
 
-func Setup(db *db.DB, sessionStore *security.SessionStore) *mux.Router {
+func Setup(db *db.DB, sessionStore *security.SessionStore, cfg *config.Config) *mux.Router {
 	r := mux.NewRouter()
+	r.Use(requestid.Middleware)
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db, sessionStore)
+	hasher := security.NewArgon2idHasherFromConfig(cfg)
+	authHandler := handlers.NewAuthHandler(db, sessionStore, hasher)
 	adminHandler := handlers.NewAdminHandler(db)
-	fileHandler := handlers.NewFileHandler(db)
+	fileHandler := handlers.NewFileHandlerFromConfig(db, cfg)
+
+	// oidcProvider is nil (and the routes below unregistered) unless
+	// oauth_client_id is set in config, so operators can disable password
+	// auth entirely by only configuring OIDC, or ignore OIDC entirely by
+	// leaving it unset.
+	oidcProvider := oidc.NewProvider(cfg, db, sessionStore)
+	csrfProtector := security.NewCSRFProtector(cfg.Secret)
+
+	if cfg.RateLimitStore != "" && cfg.RateLimitStore != "memory" {
+		log.Printf("rate_limit_store %q not implemented, falling back to in-memory store", cfg.RateLimitStore)
+	}
+	limiterStore := ratelimit.NewMemoryStore(time.Minute)
+	loginIPLimiter := ratelimit.New(limiterStore, "login-ip", 5, time.Minute, ratelimit.ByIP)
+	loginUserLimiter := ratelimit.New(limiterStore, "login-user", 5, time.Minute, ratelimit.ByJSONUsername)
+	apiIPLimiter := ratelimit.New(limiterStore, "api-ip", 100, time.Minute, ratelimit.ByIP)
+
+	onLimitDenied := func(r *http.Request, retryAfter time.Duration) {
+		audit.Event(r, "ratelimit.deny", http.StatusTooManyRequests, 0, "retry_after_seconds", int(retryAfter.Seconds()))
+	}
 
 	// Vulnerability: No CORS protection
-	// Vulnerability: No rate limiting
 	// Vulnerability: No security headers
 
 	// Public routes (no authentication required)
-	r.HandleFunc("/api/register", authHandler.Register).Methods("POST")
-	r.HandleFunc("/api/login", authHandler.Login).Methods("POST")
-	r.HandleFunc("/api/logout", authHandler.Logout).Methods("POST")
+	// csrf-token is the only unauthenticated route that passes through
+	// csrfProtector.Middleware's GET branch, so a fresh client has somewhere
+	// to obtain the csrf_token cookie before its first /api/login POST.
+	r.Handle("/api/csrf-token", apiIPLimiter.Middleware(
+		csrfProtector.Middleware(http.HandlerFunc(authHandler.CSRFToken)), onLimitDenied)).Methods("GET")
+	r.Handle("/api/register", apiIPLimiter.Middleware(http.HandlerFunc(authHandler.Register), onLimitDenied)).Methods("POST")
+	r.Handle("/api/login", loginIPLimiter.Middleware(loginUserLimiter.Middleware(
+		csrfProtector.Middleware(http.HandlerFunc(authHandler.Login)), onLimitDenied), onLimitDenied)).Methods("POST")
+	r.Handle("/api/logout", csrfProtector.Middleware(http.HandlerFunc(authHandler.Logout))).Methods("POST")
 
-	// Vulnerability: Admin routes accessible without authentication
-	r.HandleFunc("/api/admin/users", adminHandler.GetAllUsers).Methods("GET")
-	r.HandleFunc("/api/admin/search", adminHandler.SearchUsers).Methods("GET")
-	r.HandleFunc("/api/admin/users", adminHandler.DeleteUser).Methods("DELETE")
+	if oidcProvider != nil {
+		r.HandleFunc("/api/auth/oidc/login", oidcProvider.StartLogin).Methods("GET")
+		r.HandleFunc("/api/auth/oidc/callback", oidcProvider.Callback).Methods("GET")
+	}
 
-	// Vulnerability: File routes accessible without authentication
-	r.HandleFunc("/api/files/upload", fileHandler.UploadFile).Methods("POST")
-	r.HandleFunc("/api/files/download", fileHandler.DownloadFile).Methods("GET")
-	r.HandleFunc("/api/files", fileHandler.ListFiles).Methods("GET")
-	r.HandleFunc("/api/files", fileHandler.DeleteFile).Methods("DELETE")
+	// Admin routes require an authenticated admin user.
+	adminRouter := r.PathPrefix("/api/admin").Subrouter()
+	adminRouter.Use(func(next http.Handler) http.Handler {
+		return apiIPLimiter.Middleware(next, onLimitDenied)
+	})
+	adminRouter.Use(sessionStore.AuthMiddleware, security.RequireAdmin, csrfProtector.Middleware)
+	adminRouter.HandleFunc("/users", adminHandler.GetAllUsers).Methods("GET")
+	adminRouter.HandleFunc("/search", adminHandler.SearchUsers).Methods("GET")
+	adminRouter.HandleFunc("/users", adminHandler.DeleteUser).Methods("DELETE")
 
-	// Vulnerability: Static file serving without path validation
-	r.PathPrefix("/uploads/").Handler(http.StripPrefix("/uploads/", http.FileServer(http.Dir("uploads/"))))
+	// File routes require an authenticated user.
+	filesRouter := r.PathPrefix("/api/files").Subrouter()
+	filesRouter.Use(sessionStore.AuthMiddleware, csrfProtector.Middleware)
+	filesRouter.HandleFunc("/upload", fileHandler.UploadFile).Methods("POST")
+	filesRouter.HandleFunc("/download", fileHandler.DownloadFile).Methods("GET")
+	filesRouter.HandleFunc("", fileHandler.ListFiles).Methods("GET")
+	filesRouter.HandleFunc("", fileHandler.DeleteFile).Methods("DELETE")
 
-	// Vulnerability: No middleware for authentication, logging, or security
 	// Vulnerability: No input validation middleware
-	// Vulnerability: No CSRF protection
 
 	return r
 }
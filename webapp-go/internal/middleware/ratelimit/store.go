@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Store tracks per-key request counts for a token-bucket limiter. It's an
+// interface so the in-memory implementation here can be swapped for a
+// shared backend (e.g. Redis) later without touching Limiter or its
+// callers.
+type Store interface {
+	// Allow reports whether a request for key is permitted under a bucket
+	// sized limit that refills over window, and if not, how long the
+	// caller should wait before the next token is available.
+	Allow(key string, limit int, window time.Duration) (allowed bool, retryAfter time.Duration)
+}
+
+type bucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	lastSeen time.Time
+}
+
+// MemoryStore is a Store backed by a sync.Map of per-key token buckets, with
+// a background goroutine that evicts buckets that haven't been touched
+// recently so the map doesn't grow unbounded.
+type MemoryStore struct {
+	buckets sync.Map // key -> *bucket
+}
+
+// NewMemoryStore starts a MemoryStore and its background GC, which runs
+// every gcInterval and evicts buckets idle for longer than 2*gcInterval.
+func NewMemoryStore(gcInterval time.Duration) *MemoryStore {
+	s := &MemoryStore{}
+	go s.gcLoop(gcInterval)
+	return s
+}
+
+func (s *MemoryStore) gcLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := time.Now()
+		s.buckets.Range(func(key, value any) bool {
+			b := value.(*bucket)
+			b.mu.Lock()
+			idle := now.Sub(b.lastSeen) > 2*interval
+			b.mu.Unlock()
+			if idle {
+				s.buckets.Delete(key)
+			}
+			return true
+		})
+	}
+}
+
+func (s *MemoryStore) Allow(key string, limit int, window time.Duration) (bool, time.Duration) {
+	value, _ := s.buckets.LoadOrStore(key, &bucket{tokens: float64(limit), lastSeen: time.Now()})
+	b := value.(*bucket)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	refillPerSecond := float64(limit) / window.Seconds()
+	b.tokens += now.Sub(b.lastSeen).Seconds() * refillPerSecond
+	if b.tokens > float64(limit) {
+		b.tokens = float64(limit)
+	}
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / refillPerSecond * float64(time.Second))
+		return false, wait
+	}
+
+	b.tokens--
+	return true, 0
+}
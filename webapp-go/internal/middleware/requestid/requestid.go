@@ -0,0 +1,45 @@
+// Package requestid stamps every request with a trace ID so log lines for
+// the same request (across middleware and handlers) can be correlated.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+type contextKey string
+
+const traceIDKey contextKey = "trace_id"
+
+const headerName = "X-Request-ID"
+
+// Middleware assigns a trace ID to the request (reusing an inbound
+// X-Request-ID header if the caller supplied one), stashes it on the
+// request context, and echoes it back in the response header.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(headerName)
+		if id == "" {
+			id = newTraceID()
+		}
+
+		w.Header().Set(headerName, id)
+		ctx := context.WithValue(r.Context(), traceIDKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// FromContext returns the trace ID stashed by Middleware, or "" if none is
+// present (e.g. the request didn't go through it).
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey).(string)
+	return id
+}
+
+func newTraceID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -8,6 +8,7 @@ type User struct {
 	PasswordHash string    `json:"-"` // Don't expose in JSON
 	Email        string    `json:"email"`
 	IsAdmin      bool      `json:"is_admin"`
+	OIDCSubject  string    `json:"-"` // "sub" claim for users provisioned via OIDC, empty for local accounts
 	CreatedAt    time.Time `json:"created_at"`
 }
 
@@ -19,8 +20,10 @@ type Session struct {
 
 type File struct {
 	ID         int       `json:"id"`
-	Filename   string    `json:"filename"`
-	Filepath   string    `json:"filepath"`
+	Filename   string    `json:"filename"` // original filename, kept as metadata only
+	Filepath   string    `json:"-"`        // content-addressed on-disk path, not client-facing
 	UserID     int       `json:"user_id"`
+	SHA256     string    `json:"sha256"`
+	Size       int64     `json:"size"`
 	UploadedAt time.Time `json:"uploaded_at"`
 }
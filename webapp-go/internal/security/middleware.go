@@ -0,0 +1,65 @@
+package security
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"webapp-go/internal/models"
+)
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// AuthMiddleware resolves the caller's session from the session_id cookie
+// or an "Authorization: Bearer <token>" header, stashes the associated user
+// on the request context, and rejects the request with 401 if no valid
+// session is found.
+func (s *SessionStore) AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionID := sessionIDFromRequest(r)
+		if sessionID == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := s.ValidateSession(sessionID)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireAdmin rejects the request with 403 unless AuthMiddleware has
+// already placed an admin user on the request context. It must run after
+// AuthMiddleware.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := UserFromContext(r.Context())
+		if !ok || !user.IsAdmin {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UserFromContext returns the user stashed by AuthMiddleware, if any.
+func UserFromContext(ctx context.Context) (*models.User, bool) {
+	user, ok := ctx.Value(userContextKey).(*models.User)
+	return user, ok
+}
+
+func sessionIDFromRequest(r *http.Request) string {
+	if cookie, err := r.Cookie("session_id"); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
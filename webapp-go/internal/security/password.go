@@ -2,42 +2,205 @@ package security
 
 import (
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+	"webapp-go/internal/config"
+
+	"golang.org/x/crypto/argon2"
 )
 
-// Vulnerability: Using weak MD5 hashing
-func HashPassword(password string) string {
-	hash := md5.Sum([]byte(password))
-	return hex.EncodeToString(hash[:])
+// Hasher encodes and verifies password hashes. It's an interface so the
+// scheme (and its tunable cost parameters) can change without touching
+// callers.
+type Hasher interface {
+	// Hash returns a self-describing encoded hash for password.
+	Hash(password string) (encoded string, err error)
+	// Verify reports whether password matches encoded, and whether encoded
+	// should be re-hashed with the current parameters (e.g. it used a
+	// weaker scheme, or older cost parameters than are configured now).
+	Verify(encoded, password string) (ok, needsRehash bool, err error)
 }
 
-// Vulnerability: Weak password validation
-func ValidatePassword(password string) bool {
-	// Vulnerability: Only checks length, no complexity requirements
-	return len(password) >= 3
+// Argon2Params are the tunable cost parameters for Argon2idHasher. They're
+// read from config so they can be raised over time as hardware gets faster
+// without a schema change: each hash is self-describing via the PHC string.
+type Argon2Params struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+// DefaultArgon2Params are used when config leaves the argon2_* keys unset.
+var DefaultArgon2Params = Argon2Params{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 2,
+	KeyLen:  32,
+	SaltLen: 16,
 }
 
-// Vulnerability: No salt used in password hashing
-func HashPasswordWithSalt(password, salt string) string {
-	// Vulnerability: Concatenating password and salt without proper hashing
-	combined := password + salt
-	hash := md5.Sum([]byte(combined))
-	return hex.EncodeToString(hash[:])
+// Argon2idHasher hashes passwords with Argon2id and encodes them using the
+// standard PHC string format, e.g.
+// "$argon2id$v=19$m=65536,t=3,p=2$<b64salt>$<b64hash>".
+type Argon2idHasher struct {
+	params Argon2Params
 }
 
-// Vulnerability: Weak password comparison (timing attack vulnerable)
-func ComparePasswords(hashedPassword, password string) bool {
-	// Vulnerability: Direct string comparison
-	return hashedPassword == HashPassword(password)
+func NewArgon2idHasher(params Argon2Params) *Argon2idHasher {
+	return &Argon2idHasher{params: params}
+}
+
+// NewArgon2idHasherFromConfig builds an Argon2idHasher from the argon2_*
+// keys in cfg, falling back to DefaultArgon2Params for any left at zero.
+func NewArgon2idHasherFromConfig(cfg *config.Config) *Argon2idHasher {
+	params := DefaultArgon2Params
+	if cfg.Argon2Time != 0 {
+		params.Time = cfg.Argon2Time
+	}
+	if cfg.Argon2Memory != 0 {
+		params.Memory = cfg.Argon2Memory
+	}
+	if cfg.Argon2Threads != 0 {
+		params.Threads = cfg.Argon2Threads
+	}
+	if cfg.Argon2KeyLen != 0 {
+		params.KeyLen = cfg.Argon2KeyLen
+	}
+	if cfg.Argon2SaltLen != 0 {
+		params.SaltLen = cfg.Argon2SaltLen
+	}
+	return NewArgon2idHasher(params)
 }
 
-// Vulnerability: Password strength check is too lenient
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, h.params.Time, h.params.Memory, h.params.Threads, h.params.KeyLen)
+
+	return encodePHC(h.params, salt, hash), nil
+}
+
+func (h *Argon2idHasher) Verify(encoded, password string) (ok, needsRehash bool, err error) {
+	if !strings.HasPrefix(encoded, "$argon2id$") {
+		return verifyLegacyMD5(encoded, password), true, nil
+	}
+
+	params, salt, hash, err := decodePHC(encoded)
+	if err != nil {
+		return false, false, err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(hash)))
+	match := subtle.ConstantTimeCompare(candidate, hash) == 1
+
+	return match, match && params != h.params, nil
+}
+
+func encodePHC(params Argon2Params, salt, hash []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Time, params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash))
+}
+
+func decodePHC(encoded string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	var version int
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, fmt.Errorf("malformed argon2id hash")
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id version: %w", err)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id params: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id salt: %w", err)
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id hash: %w", err)
+	}
+	params.SaltLen = uint32(len(salt))
+	params.KeyLen = uint32(len(hash))
+
+	return params, salt, hash, nil
+}
+
+// verifyLegacyMD5 supports accounts created before the Argon2id migration.
+// The caller always treats a match as needing a rehash.
+func verifyLegacyMD5(encoded, password string) bool {
+	sum := md5.Sum([]byte(password))
+	want, err := hex.DecodeString(encoded)
+	if err != nil || len(want) != len(sum) {
+		return false
+	}
+	return subtle.ConstantTimeCompare(sum[:], want) == 1
+}
+
+// ValidatePassword enforces a minimum length of 12 and requires at least
+// three of {lowercase, uppercase, digit, symbol}.
+func ValidatePassword(password string) bool {
+	if len(password) < 12 {
+		return false
+	}
+	return passwordClassCount(password) >= 3
+}
+
+// CheckPasswordStrength buckets a password into "weak", "medium", or
+// "strong" based on length and character class diversity. A password that
+// fails ValidatePassword is always "weak".
 func CheckPasswordStrength(password string) string {
-	if len(password) < 3 {
+	if !ValidatePassword(password) {
 		return "weak"
-	} else if len(password) < 6 {
-		return "medium"
-	} else {
+	}
+
+	switch classes := passwordClassCount(password); {
+	case len(password) >= 16 && classes == 4:
 		return "strong"
+	case classes >= 3:
+		return "medium"
+	default:
+		return "weak"
+	}
+}
+
+func passwordClassCount(password string) int {
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{hasLower, hasUpper, hasDigit, hasSymbol} {
+		if present {
+			count++
+		}
 	}
+	return count
 }
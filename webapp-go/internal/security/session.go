@@ -2,55 +2,59 @@ package security
 
 import (
 	"crypto/rand"
-	"encoding/hex"
+	"encoding/base64"
+	"errors"
 	"time"
+	"webapp-go/internal/db"
 	"webapp-go/internal/models"
-
-	"github.com/gorilla/sessions"
 )
 
+// ErrSessionExpired is returned by ValidateSession when the session exists
+// but its expiry has passed.
+var ErrSessionExpired = errors.New("session expired")
+
+const sessionTTL = 24 * time.Hour
+
 type SessionStore struct {
-	store *sessions.CookieStore
+	db *db.DB
 }
 
-func NewSessionStore() *SessionStore {
-	// Vulnerability: Hardcoded secret key
-	secret := []byte("hardcoded-secret-key-change-in-production")
-	return &SessionStore{
-		store: sessions.NewCookieStore(secret),
-	}
+func NewSessionStore(database *db.DB) *SessionStore {
+	return &SessionStore{db: database}
 }
 
+// CreateSession issues a new cryptographically random session token for
+// userID and persists it with a real expiry.
 func (s *SessionStore) CreateSession(userID int) (string, error) {
-	// Vulnerability: Weak session ID generation
-	sessionID := generateWeakSessionID()
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	sessionID := base64.RawURLEncoding.EncodeToString(token)
+
+	if err := s.db.Sessions.Create(sessionID, userID, time.Now().Add(sessionTTL)); err != nil {
+		return "", err
+	}
 	return sessionID, nil
 }
 
-func (s *SessionStore) GetSession(sessionID string) (*models.Session, error) {
-	// Vulnerability: No session validation or expiration check
-	return &models.Session{
-		ID:        sessionID,
-		UserID:    0, // Placeholder
-		ExpiresAt: time.Now().Add(24 * time.Hour),
-	}, nil
-}
+// ValidateSession resolves a session ID to its owning user, rejecting
+// unknown or expired sessions.
+func (s *SessionStore) ValidateSession(sessionID string) (*models.User, error) {
+	session, err := s.db.Sessions.Get(sessionID)
+	if err != nil {
+		return nil, err
+	}
 
-func (s *SessionStore) ValidateSession(sessionID string) bool {
-	// Vulnerability: Always returns true - no actual validation
-	return true
-}
+	if time.Now().After(session.ExpiresAt) {
+		_ = s.db.Sessions.Delete(sessionID)
+		return nil, ErrSessionExpired
+	}
 
-// Vulnerability: Weak session ID generation
-func generateWeakSessionID() string {
-	// Vulnerability: Using predictable seed and weak random generation
-	bytes := make([]byte, 8)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes)
+	return s.db.Users.GetByID(session.UserID)
 }
 
-// Vulnerability: No CSRF protection
-func (s *SessionStore) ValidateCSRFToken(token string) bool {
-	// Vulnerability: Always returns true - no CSRF validation
-	return true
+// DeleteSession invalidates a session immediately, e.g. on logout.
+func (s *SessionStore) DeleteSession(sessionID string) error {
+	return s.db.Sessions.Delete(sessionID)
 }